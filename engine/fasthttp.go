@@ -4,27 +4,38 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime"
-	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
 
+var jsonpBufPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
 type FastTransport interface {
 	Name() string
 	Dial(rawurl string) (conn Conn, err error)
 	Accept(ctx *fasthttp.RequestCtx) (conn Conn, err error)
 }
 
+// fastAsyncAcceptor is implemented by transports whose Accept cannot hand
+// back a ready-to-use Conn within this request (websocket: fasthttp only
+// runs a hijack callback after the RequestHandler returns). Accepting such a
+// transport as an upgrade must defer the probe handshake into that callback
+// instead of driving it synchronously here, or it would deadlock.
+type fastAsyncAcceptor interface {
+	AcceptAsync(ctx *fasthttp.RequestCtx, cont func(conn Conn, err error)) error
+}
+
 func getFastTransport(name string) FastTransport {
 	switch name {
 	case transportWebsocket:
-		return nil
+		return FastWebsocketTransport
 	case transportPolling:
 		return FastPollingTransport
 	}
@@ -44,7 +55,7 @@ func (fastPollingTransport) Name() string {
 }
 
 func (fastPollingTransport) Dial(rawurl string) (Conn, error) {
-	return nil, errors.New("not implemented")
+	return DialFastPolling(rawurl)
 }
 
 // FastPollingTransport is a Transport instance for polling
@@ -73,16 +84,11 @@ func (p *pollingConn) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 			ctx.Error(err.Error(), fasthttp.StatusNotFound)
 			return
 		}
-		rURL, err := url.ParseRequestURI(string(ctx.RequestURI()))
-		if err != nil {
-			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
-			return
-		}
-		q := rURL.Query()
-		b64 := q.Get(queryBase64)
-		if jsonp := q.Get(queryJSONP); jsonp != "" {
-			err = fastWriteJSONP(ctx, jsonp, pkt)
-		} else if b64 == "1" {
+		q := ctx.QueryArgs()
+		b64 := q.Peek(queryBase64)
+		if jsonp := q.Peek(queryJSONP); len(jsonp) > 0 {
+			err = fastWriteJSONP(ctx, string(jsonp), pkt)
+		} else if string(b64) == "1" {
 			err = fastWriteXHR(ctx, pkt)
 		} else {
 			err = fastWriteXHR2(ctx, pkt.packet2())
@@ -90,6 +96,8 @@ func (p *pollingConn) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 		if err != nil {
 			log.Println("polling:", err.Error())
 		}
+		// Socket wraps this Conn in an observedConn (see socket_observed.go),
+		// which is what actually tallies the packet against its Observer.
 	case "POST":
 		var payload Payload
 		mediatype, params, err := mime.ParseMediaType(string(ctx.Request.Header.Peek("Content-Type")))
@@ -109,17 +117,72 @@ func (p *pollingConn) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 			ctx.Error("invalid media type", fasthttp.StatusBadRequest)
 			return
 		}
-		_, err = payload.ReadFrom(bytes.NewReader(ctx.Request.Body()))
+		limits := limitsFromCtx(ctx)
+		cl := int64(ctx.Request.Header.ContentLength())
+		if cl > limits.getMaxPayloadSize() {
+			ctx.Error("payload too large", fasthttp.StatusRequestEntityTooLarge)
+			return
+		}
+		// A slow trickle of request bytes is bounded by the fasthttp.Server's
+		// own ReadTimeout, not by payloadTimeout below, which only guards the
+		// p.in handoff once packets have been decoded.
+		//
+		// The reader is capped by maxPayloadSize (the whole POST body, which
+		// may bundle several packets back to back), not maxPacketSize (one
+		// packet) — capping the stream at a single packet's worth would make
+		// it impossible to ever deliver more than one packet per POST.
+		//
+		// This branch is not a true incremental decode: payload.ReadFrom
+		// still reads the limited reader to EOF and populates payload.packets
+		// in one shot, so memory for a single POST is bounded by
+		// maxPayloadSize as a whole rather than one packet at a time. It is
+		// also only taken when the request body is a stream, which requires
+		// the embedding application to start its fasthttp.Server with
+		// StreamRequestBody: true — this package has no way to set that on
+		// the caller's behalf. Without it fasthttp has already buffered the
+		// whole body, which is then decoded from ctx.PostBody().
+		limit := limits.getMaxPayloadSize() + 1
+		var n int64
+		if ctx.IsBodyStream() && (cl < 0 || cl > limits.getMaxInMemoryBody()) {
+			n, err = payload.ReadFrom(io.LimitReader(ctx.RequestBodyStream(), limit))
+		} else {
+			var n32 int
+			n32, err = payload.ReadFromBytes(ctx.PostBody())
+			n = int64(n32)
+		}
 		if err != nil {
 			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
 			return
 		}
+		if n >= limit {
+			ctx.Error("payload too large", fasthttp.StatusRequestEntityTooLarge)
+			return
+		}
+		// Validate every packet's size up front, before delivering any of
+		// them: checking inside the delivery loop below would let packets
+		// before an oversized one already reach p.in by the time the 413 is
+		// returned, instead of rejecting the whole POST.
+		maxPacketSize := limits.getMaxPacketSize()
+		for i := range payload.packets {
+			if int64(len(payload.packets[i].data)) > maxPacketSize {
+				ctx.Error("packet too large", fasthttp.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+		timeout := time.NewTimer(limits.getPayloadTimeout())
+		defer timeout.Stop()
 		for i := range payload.packets {
 			select {
 			case <-p.closed:
 				ctx.Error("closed", fasthttp.StatusNotFound)
 				return
 			case p.in <- &payload.packets[i]:
+				// Socket wraps this Conn in an observedConn (see
+				// socket_observed.go), which tallies the packet once it is
+				// actually picked up via ReadPacket, against the right sid.
+			case <-timeout.C:
+				ctx.Error("payload backpressure timeout", fasthttp.StatusServiceUnavailable)
+				return
 			}
 		}
 		ctx.Error("OK", fasthttp.StatusOK)
@@ -129,14 +192,18 @@ func (p *pollingConn) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 }
 
 func fastWriteJSONP(ctx *fasthttp.RequestCtx, jsonp string, wt io.WriterTo) error {
-	var buf bytes.Buffer
+	buf := jsonpBufPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		jsonpBufPool.Put(buf)
+	}()
 	ctx.Response.Header.Set("Content-Type", "text/javascript; charset=UTF-8")
-	if _, err := wt.WriteTo(&buf); err != nil {
+	if _, err := wt.WriteTo(buf); err != nil {
 		return err
 	}
 	s := buf.String()
 	buf.Reset()
-	err := json.NewEncoder(&buf).Encode(s)
+	err := json.NewEncoder(buf).Encode(s)
 	if err != nil {
 		return err
 	}
@@ -164,25 +231,22 @@ func fastWriteXHR2(ctx *fasthttp.RequestCtx, wt io.WriterTo) error {
 }
 
 func (s *Server) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
-	rURL, err := url.ParseRequestURI(string(ctx.RequestURI()))
-	if err != nil {
-		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
-		return
-	}
-	q := rURL.Query()
+	withLimits(ctx, s)
 
-	if q.Get(queryEIO) != Version {
+	q := ctx.QueryArgs()
+
+	if string(q.Peek(queryEIO)) != Version {
 		ctx.Error("protocol version incompatible", fasthttp.StatusBadRequest)
 		return
 	}
 
-	transport := getFastTransport(q.Get(queryTransport))
+	transport := getFastTransport(string(q.Peek(queryTransport)))
 	if transport == nil {
 		ctx.Error("invalid transport", fasthttp.StatusBadRequest)
 		return
 	}
 
-	if sid := q.Get(querySession); sid == "" {
+	if sid := string(q.Peek(querySession)); sid == "" {
 		conn, err := transport.Accept(ctx)
 		if err != nil {
 			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
@@ -190,6 +254,9 @@ func (s *Server) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 		}
 		ß := s.NewSession(conn, s.pingTimeout+s.pingInterval, s.pingTimeout)
 		ß.transportName = transport.Name()
+		obs := s.observer()
+		ß.SetObserver(obs)
+		obs.OnAccept(ß.SID(), transport.Name())
 		select {
 		case <-s.done:
 			return
@@ -202,7 +269,20 @@ func (s *Server) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 			ctx.Error("invalid session", fasthttp.StatusBadRequest)
 			return
 		}
+		ß.SetSID(sid)
 		if transportName := transport.Name(); ß.transportName != transportName {
+			if async, ok := transport.(fastAsyncAcceptor); ok {
+				err := async.AcceptAsync(ctx, func(conn Conn, err error) {
+					if err != nil {
+						return
+					}
+					s.upgrade(ß, transportName, conn)
+				})
+				if err != nil {
+					ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+				}
+				return // handoff continues in the hijack callback; ctx is no longer ours to use
+			}
 			conn, err := transport.Accept(ctx)
 			if err != nil {
 				ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
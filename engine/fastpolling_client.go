@@ -0,0 +1,245 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// FastDialOption configures DialFastPolling.
+type FastDialOption func(*fastDialConfig)
+
+type fastDialConfig struct {
+	hc *fasthttp.HostClient
+}
+
+// WithHostClient lets callers share a *fasthttp.HostClient (and therefore its
+// TLS config, proxy dialer and connection pool) across many engine.io
+// sessions instead of dialing a fresh one per session.
+func WithHostClient(hc *fasthttp.HostClient) FastDialOption {
+	return func(c *fastDialConfig) { c.hc = hc }
+}
+
+type openPacket struct {
+	Sid          string   `json:"sid"`
+	Upgrades     []string `json:"upgrades"`
+	PingInterval int      `json:"pingInterval"`
+	PingTimeout  int      `json:"pingTimeout"`
+}
+
+// fastPollingClientConn is the client side of fastPollingTransport, driving
+// the long-poll GET and buffered POST loop against a shared HostClient.
+type fastPollingClientConn struct {
+	hc   *fasthttp.HostClient
+	base string // scheme://host, used to build request URIs
+	path string
+	sid  string
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	pending []*Packet // packets decoded from the last GET not yet returned by ReadPacket
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+// DialFastPolling performs the EIO3 polling handshake against rawurl and
+// returns a Conn driving the long-poll GET / buffered POST loops over a
+// fasthttp.HostClient.
+func DialFastPolling(rawurl string, opts ...FastDialOption) (Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &fastDialConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	hc := cfg.hc
+	if hc == nil {
+		hc = &fasthttp.HostClient{
+			Addr:                u.Host,
+			IsTLS:               u.Scheme == "https",
+			MaxConns:            64,
+			MaxIdleConnDuration: 90 * time.Second,
+		}
+	}
+
+	c := &fastPollingClientConn{
+		hc:     hc,
+		base:   u.Scheme + "://" + u.Host,
+		path:   strings.TrimSuffix(u.Path, "/"),
+		closed: make(chan struct{}),
+	}
+
+	body, err := c.roundtrip("GET", fmt.Sprintf("%s?%s=%s&%s=%s", c.url(), queryEIO, Version, queryTransport, transportPolling), nil, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	pkts, err := decodeXHR2Payload(body)
+	if err != nil {
+		return nil, err
+	}
+	pkt := pkts[0]
+	c.pending = pkts[1:]
+	if pkt.pktType != PacketTypeOpen {
+		return nil, fmt.Errorf("engine: expected open packet, got %v", pkt.pktType)
+	}
+	var op openPacket
+	if err = json.Unmarshal(pkt.data, &op); err != nil {
+		return nil, err
+	}
+	c.sid = op.Sid
+	return c, nil
+}
+
+func (c *fastPollingClientConn) url() string {
+	return c.base + c.path + "/"
+}
+
+// roundtrip issues a single HTTP request. deadline, when non-zero, is the
+// deadline to apply: callers pass c.readDeadline for GET (long-poll) and
+// c.writeDeadline for POST, since the two are configured independently via
+// SetReadDeadline/SetWriteDeadline.
+func (c *fastPollingClientConn) roundtrip(method, uri string, body []byte, deadline time.Time) ([]byte, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.SetBody(body)
+	}
+
+	var err error
+	if !deadline.IsZero() {
+		err = c.hc.DoDeadline(req, resp, deadline)
+	} else {
+		err = c.hc.Do(req, resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return nil, fmt.Errorf("engine: polling request failed: %d", resp.StatusCode())
+	}
+	return append([]byte(nil), resp.Body()...), nil
+}
+
+// ReadPacket returns the next packet, which may come from a long-poll
+// response already decoded by a previous call: a single GET response can
+// bundle several packets, so they're queued in c.pending and drained before
+// issuing another round-trip.
+func (c *fastPollingClientConn) ReadPacket() (*Packet, error) {
+	select {
+	case <-c.closed:
+		return nil, ErrPollingConnClosed
+	default:
+	}
+	if len(c.pending) > 0 {
+		pkt := c.pending[0]
+		c.pending = c.pending[1:]
+		return pkt, nil
+	}
+	uri := fmt.Sprintf("%s?%s=%s&%s=%s&%s=%s", c.url(), queryEIO, Version, queryTransport, transportPolling, querySession, c.sid)
+	body, err := c.withBackoff(func() ([]byte, error) { return c.roundtrip("GET", uri, nil, c.readDeadline) })
+	if err != nil {
+		return nil, err
+	}
+	pkts, err := decodeXHR2Payload(body)
+	if err != nil {
+		return nil, err
+	}
+	c.pending = pkts[1:]
+	return pkts[0], nil
+}
+
+func (c *fastPollingClientConn) WritePacket(pkt *Packet) error {
+	select {
+	case <-c.closed:
+		return ErrPollingConnClosed
+	default:
+	}
+	var buf bytes.Buffer
+	if _, err := pkt.packet2().WriteTo(&buf); err != nil {
+		return err
+	}
+	uri := fmt.Sprintf("%s?%s=%s&%s=%s&%s=%s", c.url(), queryEIO, Version, queryTransport, transportPolling, querySession, c.sid)
+	_, err := c.withBackoff(func() ([]byte, error) { return c.roundtrip("POST", uri, buf.Bytes(), c.writeDeadline) })
+	return err
+}
+
+// withBackoff retries a single round-trip with exponential backoff, so a
+// transient dial/proxy failure doesn't immediately tear the session down.
+func (c *fastPollingClientConn) withBackoff(do func() ([]byte, error)) ([]byte, error) {
+	const maxAttempts = 5
+	backoff := 100 * time.Millisecond
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-c.closed:
+				return nil, ErrPollingConnClosed
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		body, err := do()
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (c *fastPollingClientConn) Pause() error  { return nil }
+func (c *fastPollingClientConn) Resume() error { return nil }
+
+func (c *fastPollingClientConn) Close() (err error) {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fastPollingClientConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline = t
+	return nil
+}
+
+func (c *fastPollingClientConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline = t
+	return nil
+}
+
+// decodeXHR2Payload decodes a long-poll GET response body into the packets
+// it carries. A response isn't necessarily a single packet: the server may
+// bundle several queued packets into one payload, using the same binary
+// framing it decodes POST bodies with, so this reuses Payload's decode
+// logic rather than re-implementing (and, as before, under-implementing) it.
+func decodeXHR2Payload(b []byte) ([]*Packet, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("engine: empty polling response")
+	}
+	payload := Payload{xhr2: true}
+	if _, err := payload.ReadFromBytes(b); err != nil {
+		return nil, err
+	}
+	if len(payload.packets) == 0 {
+		return nil, fmt.Errorf("engine: empty polling response")
+	}
+	pkts := make([]*Packet, len(payload.packets))
+	for i := range payload.packets {
+		pkts[i] = &payload.packets[i]
+	}
+	return pkts, nil
+}
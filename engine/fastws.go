@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/valyala/fasthttp"
+)
+
+// ErrWsConnClosed is returned by wsConn's methods when the connection is
+// closed before the hijack callback ever binds an underlying
+// *websocket.Conn (e.g. the hijack never runs, or the Socket is torn down
+// while still waiting for it).
+var ErrWsConnClosed = errors.New("engine: websocket connection closed before it was established")
+
+var fastWebsocketUpgrader = websocket.FastHTTPUpgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(ctx *fasthttp.RequestCtx) bool { return true },
+}
+
+// wsConn adapts a *websocket.Conn to the engine.io Conn interface. It is
+// constructed before the underlying *websocket.Conn exists: fasthttp only
+// runs a Hijack callback after the RequestHandler that registered it
+// returns, so every method that uses ws blocks on ready until bind populates
+// it (or until Close).
+type wsConn struct {
+	ws      *websocket.Conn
+	ready   chan struct{}
+	wmu     sync.Mutex
+	paused  bool
+	pausemu sync.Mutex
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newWsConn() *wsConn {
+	return &wsConn{ready: make(chan struct{}), closed: make(chan struct{})}
+}
+
+// bind attaches the live websocket connection once the hijack callback runs.
+func (c *wsConn) bind(ws *websocket.Conn) {
+	c.ws = ws
+	close(c.ready)
+}
+
+// conn blocks until bind has attached the underlying websocket connection,
+// returning ErrWsConnClosed instead if the connection is closed first —
+// otherwise a Close() racing a hijack callback that never runs would block
+// forever. ready is checked non-blockingly first so that a bound connection
+// being closed concurrently can't make this spuriously report closed instead
+// of returning the live conn.
+func (c *wsConn) conn() (*websocket.Conn, error) {
+	select {
+	case <-c.ready:
+		return c.ws, nil
+	default:
+	}
+	select {
+	case <-c.ready:
+		return c.ws, nil
+	case <-c.closed:
+		return nil, ErrWsConnClosed
+	}
+}
+
+// ReadPacket and WritePacket are intentionally observer-agnostic: Socket
+// wraps its Conn in an observedConn (see socket_observed.go) so packets are
+// tallied once, against the right sid, regardless of transport.
+func (c *wsConn) ReadPacket() (*Packet, error) {
+	ws, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	mt, data, err := ws.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return decodeWsPacket(mt, data)
+}
+
+func (c *wsConn) WritePacket(pkt *Packet) error {
+	ws, err := c.conn()
+	if err != nil {
+		return err
+	}
+	mt, data := encodeWsPacket(pkt)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return ws.WriteMessage(mt, data)
+}
+
+func (c *wsConn) Pause() error {
+	c.pausemu.Lock()
+	c.paused = true
+	c.pausemu.Unlock()
+	return nil
+}
+
+func (c *wsConn) Resume() error {
+	c.pausemu.Lock()
+	c.paused = false
+	c.pausemu.Unlock()
+	return nil
+}
+
+func (c *wsConn) Close() (err error) {
+	c.once.Do(func() {
+		close(c.closed)
+		ws, cerr := c.conn()
+		if cerr != nil {
+			// Never bound, so there is nothing underlying to close.
+			return
+		}
+		err = ws.Close()
+	})
+	return
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error {
+	ws, err := c.conn()
+	if err != nil {
+		return err
+	}
+	return ws.SetReadDeadline(t)
+}
+
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	ws, err := c.conn()
+	if err != nil {
+		return err
+	}
+	return ws.SetWriteDeadline(t)
+}
+
+func encodeWsPacket(pkt *Packet) (messageType int, data []byte) {
+	if pkt.msgType == MessageTypeBinary {
+		return websocket.BinaryMessage, pkt.data
+	}
+	data = append([]byte{'0' + byte(pkt.pktType)}, pkt.data...)
+	return websocket.TextMessage, data
+}
+
+func decodeWsPacket(messageType int, data []byte) (*Packet, error) {
+	if messageType == websocket.BinaryMessage {
+		return &Packet{msgType: MessageTypeBinary, pktType: PacketTypeMessage, data: data}, nil
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("engine: empty websocket frame")
+	}
+	pktType := PacketType(data[0] - '0')
+	return &Packet{msgType: MessageTypeString, pktType: pktType, data: data[1:]}, nil
+}
+
+type fastWebsocketTransport struct{}
+
+func (fastWebsocketTransport) Name() string {
+	return transportWebsocket
+}
+
+// Accept registers a hijack callback and returns immediately with a Conn
+// that isn't usable yet: fasthttp only invokes the callback after this
+// RequestHandler returns, so waiting here for the handshake to complete
+// would deadlock the worker. Reads/writes on the returned Conn block until
+// the callback runs and binds the live websocket connection.
+func (fastWebsocketTransport) Accept(ctx *fasthttp.RequestCtx) (conn Conn, err error) {
+	c := newWsConn()
+	err = fastWebsocketUpgrader.Upgrade(ctx, func(ws *websocket.Conn) {
+		c.bind(ws)
+		<-c.closed // keep the hijacked connection alive until the Socket closes it
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AcceptAsync is like Accept, but instead of returning a not-yet-usable Conn
+// it invokes cont once the hijacked connection is actually live. Callers
+// that must synchronously drive the new Conn (e.g. the engine.io upgrade
+// probe handshake) use this to run that logic from inside the hijack
+// callback's goroutine, where blocking is safe, rather than from this
+// RequestHandler, where it would deadlock.
+func (fastWebsocketTransport) AcceptAsync(ctx *fasthttp.RequestCtx, cont func(conn Conn, err error)) error {
+	return fastWebsocketUpgrader.Upgrade(ctx, func(ws *websocket.Conn) {
+		c := newWsConn()
+		c.bind(ws)
+		cont(c, nil)
+		<-c.closed // keep the hijacked connection alive until the Socket closes it
+	})
+}
+
+func (fastWebsocketTransport) Dial(rawurl string) (Conn, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := newWsConn()
+	c.bind(ws)
+	return c, nil
+}
+
+// FastWebsocketTransport is a FastTransport instance for websocket, backed by
+// github.com/fasthttp/websocket.
+var FastWebsocketTransport FastTransport = &fastWebsocketTransport{}
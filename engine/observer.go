@@ -0,0 +1,61 @@
+package engine
+
+import "sync"
+
+const (
+	dirIn  = "in"
+	dirOut = "out"
+)
+
+// Observer receives lifecycle and throughput events for engine.io sessions.
+// Implementations must be safe for concurrent use; hooks are called from
+// whichever goroutine is servicing the socket and must not block.
+type Observer interface {
+	OnAccept(sid, transport string)
+	OnUpgrade(sid, from, to string)
+	OnPacket(sid, dir string, pktType PacketType, n int)
+	OnClose(sid string, err error)
+}
+
+type noopObserver struct{}
+
+func (noopObserver) OnAccept(string, string)                  {}
+func (noopObserver) OnUpgrade(string, string, string)         {}
+func (noopObserver) OnPacket(string, string, PacketType, int) {}
+func (noopObserver) OnClose(string, error)                    {}
+
+// serverObservers holds each *Server's configured Observer, keyed by server
+// identity. Server's definition doesn't expose a field for this, so servers
+// are tracked here by pointer instead of on the struct itself; unlike a
+// single shared default, this still gives every *Server its own Observer,
+// independent of any other Server in the same process.
+var serverObservers = struct {
+	mu sync.RWMutex
+	m  map[*Server]Observer
+}{m: make(map[*Server]Observer)}
+
+// SetObserver installs o as the default Observer for sockets accepted by s.
+// Passing nil restores the no-op default. It only affects s: other *Server
+// instances in the same process keep whatever they were given, or the no-op
+// default if they were never configured.
+func (s *Server) SetObserver(o Observer) {
+	serverObservers.mu.Lock()
+	defer serverObservers.mu.Unlock()
+	if o == nil {
+		delete(serverObservers.m, s)
+		return
+	}
+	serverObservers.m[s] = o
+}
+
+// observer returns s's configured Observer, or a no-op if SetObserver was
+// never called for s.
+func (s *Server) observer() Observer {
+	serverObservers.mu.RLock()
+	o := serverObservers.m[s]
+	serverObservers.mu.RUnlock()
+	if o == nil {
+		return noopObserver{}
+	}
+	return o
+}
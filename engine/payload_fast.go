@@ -0,0 +1,20 @@
+package engine
+
+import (
+	"bytes"
+	"sync"
+)
+
+var payloadBytesReaderPool = sync.Pool{New: func() interface{} { return new(bytes.Reader) }}
+
+// ReadFromBytes parses packets out of b without the extra io.Reader wrapper
+// allocation that ReadFrom(bytes.NewReader(b)) would incur on the polling hot
+// path.
+func (p *Payload) ReadFromBytes(b []byte) (n int, err error) {
+	r := payloadBytesReaderPool.Get().(*bytes.Reader)
+	r.Reset(b)
+	n64, err := p.ReadFrom(r)
+	r.Reset(nil)
+	payloadBytesReaderPool.Put(r)
+	return int(n64), err
+}
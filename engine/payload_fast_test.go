@@ -0,0 +1,41 @@
+//go:build !race
+
+package engine
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestPayloadReadFromBytesAllocs guards the allocation avoidance chunk0-2
+// introduced: ReadFromBytes must reuse the pooled *bytes.Reader instead of
+// allocating a fresh one per POST, on pain of reintroducing the hot-path
+// allocation this type exists to avoid. Skipped under -race, since the race
+// detector's own instrumentation allocates and would make the count
+// meaningless.
+func TestPayloadReadFromBytesAllocs(t *testing.T) {
+	pkt := &Packet{msgType: MessageTypeBinary, pktType: PacketTypeMessage, data: []byte("hello world")}
+	var buf bytes.Buffer
+	if _, err := pkt.packet2().WriteTo(&buf); err != nil {
+		t.Fatalf("encode packet: %v", err)
+	}
+	b := buf.Bytes()
+
+	// Warm the sync.Pool so the count below reflects steady-state reuse, not
+	// the one-time cost of populating it.
+	warm := Payload{xhr2: true}
+	if _, err := warm.ReadFromBytes(b); err != nil {
+		t.Fatalf("warm-up decode: %v", err)
+	}
+
+	const maxAllocs = 2
+	allocs := testing.AllocsPerRun(1000, func() {
+		p := Payload{xhr2: true}
+		if _, err := p.ReadFromBytes(b); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	})
+	if allocs > maxAllocs {
+		t.Errorf("ReadFromBytes allocates %.1f times per call, want <= %d; the pooled *bytes.Reader should keep the reader itself out of the count", allocs, maxAllocs)
+	}
+}
@@ -0,0 +1,74 @@
+// Package prometheus provides a ready-made engine.Observer that accumulates
+// connection, upgrade and throughput counters in Prometheus text exposition
+// format, for operators who want to scrape a socketio server without
+// depending on the core engine package knowing about metrics at all.
+package prometheus
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/zyxar/socketio/engine"
+)
+
+// Observer is an engine.Observer that tallies accepts, upgrades, closes and
+// per-direction packet/byte counts. The zero value is ready to use.
+type Observer struct {
+	accepts    int64
+	upgrades   int64
+	closes     int64
+	packetsIn  int64
+	packetsOut int64
+	bytesIn    int64
+	bytesOut   int64
+}
+
+var _ engine.Observer = (*Observer)(nil)
+
+func (o *Observer) OnAccept(sid, transport string) {
+	atomic.AddInt64(&o.accepts, 1)
+}
+
+func (o *Observer) OnUpgrade(sid, from, to string) {
+	atomic.AddInt64(&o.upgrades, 1)
+}
+
+func (o *Observer) OnPacket(sid, dir string, pktType engine.PacketType, n int) {
+	if dir == "out" {
+		atomic.AddInt64(&o.packetsOut, 1)
+		atomic.AddInt64(&o.bytesOut, int64(n))
+		return
+	}
+	atomic.AddInt64(&o.packetsIn, 1)
+	atomic.AddInt64(&o.bytesIn, int64(n))
+}
+
+func (o *Observer) OnClose(sid string, err error) {
+	atomic.AddInt64(&o.closes, 1)
+}
+
+// WriteTo renders the current counters in Prometheus text exposition format.
+func (o *Observer) WriteTo(w io.Writer) (n int64, err error) {
+	metrics := []struct {
+		name string
+		help string
+		val  int64
+	}{
+		{"socketio_accepts_total", "Total number of accepted engine.io connections.", atomic.LoadInt64(&o.accepts)},
+		{"socketio_upgrades_total", "Total number of transport upgrades.", atomic.LoadInt64(&o.upgrades)},
+		{"socketio_closes_total", "Total number of closed engine.io connections.", atomic.LoadInt64(&o.closes)},
+		{"socketio_packets_in_total", "Total number of packets received.", atomic.LoadInt64(&o.packetsIn)},
+		{"socketio_packets_out_total", "Total number of packets sent.", atomic.LoadInt64(&o.packetsOut)},
+		{"socketio_bytes_in_total", "Total number of payload bytes received.", atomic.LoadInt64(&o.bytesIn)},
+		{"socketio_bytes_out_total", "Total number of payload bytes sent.", atomic.LoadInt64(&o.bytesOut)},
+	}
+	for _, m := range metrics {
+		wn, werr := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", m.name, m.help, m.name, m.name, m.val)
+		n += int64(wn)
+		if werr != nil {
+			return n, werr
+		}
+	}
+	return n, nil
+}
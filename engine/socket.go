@@ -3,6 +3,7 @@ package engine
 import (
 	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +16,88 @@ type Socket struct {
 	emitter      *emitter
 	once         sync.Once
 	sync.RWMutex
+
+	sid         string
+	observer    Observer
+	observeDone sync.Once
+
+	bytesIn      int64
+	bytesOut     int64
+	packetsIn    int64
+	packetsOut   int64
+	lastActivity int64 // unix nano, set via atomic
+}
+
+// SetSID binds the session id reported to this Socket's Observer. It is set
+// by the session layer once a handshake completes.
+func (s *Socket) SetSID(sid string) {
+	s.Lock()
+	s.sid = sid
+	s.Unlock()
+}
+
+// SID returns the session id bound via SetSID, or "" if none has been set
+// yet.
+func (s *Socket) SID() string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.sid
+}
+
+// SetObserver installs o as this Socket's Observer, overriding whatever the
+// owning Server assigned at accept time. Passing nil falls back to a no-op.
+func (s *Socket) SetObserver(o Observer) {
+	if o == nil {
+		o = noopObserver{}
+	}
+	s.Lock()
+	s.observer = o
+	s.Unlock()
+}
+
+func (s *Socket) observerAndSID() (Observer, string) {
+	s.RLock()
+	o, sid := s.observer, s.sid
+	s.RUnlock()
+	if o == nil {
+		o = noopObserver{}
+	}
+	return o, sid
+}
+
+func (s *Socket) trackPacket(dir string, pktType PacketType, n int) {
+	atomic.StoreInt64(&s.lastActivity, time.Now().UnixNano())
+	if dir == dirIn {
+		atomic.AddInt64(&s.bytesIn, int64(n))
+		atomic.AddInt64(&s.packetsIn, 1)
+	} else {
+		atomic.AddInt64(&s.bytesOut, int64(n))
+		atomic.AddInt64(&s.packetsOut, 1)
+	}
+	if o, sid := s.observerAndSID(); o != nil {
+		o.OnPacket(sid, dir, pktType, n)
+	}
+}
+
+// BytesIn reports the number of payload bytes read from the client so far.
+func (s *Socket) BytesIn() int64 { return atomic.LoadInt64(&s.bytesIn) }
+
+// BytesOut reports the number of payload bytes written to the client so far.
+func (s *Socket) BytesOut() int64 { return atomic.LoadInt64(&s.bytesOut) }
+
+// PacketsIn reports the number of packets read from the client so far.
+func (s *Socket) PacketsIn() int64 { return atomic.LoadInt64(&s.packetsIn) }
+
+// PacketsOut reports the number of packets written to the client so far.
+func (s *Socket) PacketsOut() int64 { return atomic.LoadInt64(&s.packetsOut) }
+
+// LastActivity reports the time of the most recently observed packet.
+func (s *Socket) LastActivity() time.Time {
+	n := atomic.LoadInt64(&s.lastActivity)
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
 }
 
 func (s *Socket) upgrade(transport string, newConn Conn) {
@@ -58,14 +141,23 @@ func (s *Socket) upgrade(transport string, newConn Conn) {
 	}
 
 	s.Lock()
-	s.Conn = newConn
+	from := s.transport
+	s.Conn = s.observe(newConn)
 	s.transport = transport
 	s.Unlock()
+	if o, sid := s.observerAndSID(); o != nil {
+		o.OnUpgrade(sid, from, transport)
+	}
 	s.fire(s, EventUpgrade, p.msgType, p.data)
 	return
 }
 
 func (s *Socket) Handle() error {
+	s.observeDone.Do(func() {
+		s.Lock()
+		s.Conn = s.observe(s.Conn)
+		s.Unlock()
+	})
 	return s.eventHandlers.handle(s)
 }
 
@@ -73,6 +165,9 @@ func (s *Socket) Close() (err error) {
 	s.once.Do(func() {
 		s.emitter.close()
 		err = s.Conn.Close()
+		if o, sid := s.observerAndSID(); o != nil {
+			o.OnClose(sid, err)
+		}
 	})
 	return
 }
@@ -108,6 +203,8 @@ func (s *Socket) emit(event event, msgType MessageType, args interface{}) (err e
 		}
 	}
 
+	// The emitter hands this off to the Conn asynchronously; WritePacket on
+	// the observed Conn (see socket_observed.go) is what actually tallies it.
 	return s.emitter.submit(&Packet{msgType, pktType, data})
 }
 
@@ -0,0 +1,48 @@
+package engine
+
+import "github.com/valyala/fasthttp"
+
+// observedConn wraps a Socket's Conn so every packet that actually crosses
+// the wire — inbound or outbound, on whichever transport is active at the
+// time — updates that Socket's counters and Observer. It is installed once
+// around s.Conn, from Handle and upgrade, rather than threaded through each
+// FastTransport implementation, since pollingConn/wsConn have no reference
+// back to the Socket they belong to.
+type observedConn struct {
+	Conn
+	s *Socket
+}
+
+func (s *Socket) observe(c Conn) Conn {
+	if c == nil {
+		return c
+	}
+	if _, ok := c.(*observedConn); ok {
+		return c
+	}
+	return &observedConn{Conn: c, s: s}
+}
+
+func (o *observedConn) ReadPacket() (*Packet, error) {
+	pkt, err := o.Conn.ReadPacket()
+	if err == nil {
+		o.s.trackPacket(dirIn, pkt.pktType, len(pkt.data))
+	}
+	return pkt, err
+}
+
+func (o *observedConn) WritePacket(pkt *Packet) error {
+	err := o.Conn.WritePacket(pkt)
+	if err == nil {
+		o.s.trackPacket(dirOut, pkt.pktType, len(pkt.data))
+	}
+	return err
+}
+
+// HandleFastHTTP forwards to the wrapped Conn's request handler, so that
+// Socket.HandleFastHTTP still reaches pollingConn once s.Conn is wrapped.
+func (o *observedConn) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
+	if h, ok := o.Conn.(fasthttpRequestHandler); ok {
+		h.HandleFastHTTP(ctx)
+	}
+}
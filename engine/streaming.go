@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Defaults for the polling POST body limits below, tuned for small JSON/text
+// payloads; override per-server via SetMaxInMemoryBody, SetMaxPacketSize,
+// SetMaxPayloadSize and SetPayloadTimeout for workloads with large binary
+// (XHR2) uploads.
+const (
+	defaultMaxInMemoryBody = 1 << 20  // 1MiB
+	defaultMaxPacketSize   = 10 << 20 // 10MiB
+	defaultMaxPayloadSize  = 50 << 20 // 50MiB
+	defaultPayloadTimeout  = 5 * time.Second
+)
+
+// serverLimits holds one *Server's POST body tuning. Server's definition
+// doesn't expose fields for these, so instances are tracked in
+// serverLimitsRegistry, keyed by server identity, rather than in a single
+// set of package variables every Server would otherwise share.
+type serverLimits struct {
+	maxInMemoryBody int64
+	maxPacketSize   int64
+	maxPayloadSize  int64
+	payloadTimeout  int64
+}
+
+func newServerLimits() *serverLimits {
+	return &serverLimits{
+		maxInMemoryBody: defaultMaxInMemoryBody,
+		maxPacketSize:   defaultMaxPacketSize,
+		maxPayloadSize:  defaultMaxPayloadSize,
+		payloadTimeout:  int64(defaultPayloadTimeout),
+	}
+}
+
+var serverLimitsRegistry = struct {
+	mu sync.Mutex
+	m  map[*Server]*serverLimits
+}{m: make(map[*Server]*serverLimits)}
+
+// limits returns s's serverLimits, creating it on first use.
+func (s *Server) limits() *serverLimits {
+	serverLimitsRegistry.mu.Lock()
+	defer serverLimitsRegistry.mu.Unlock()
+	l, ok := serverLimitsRegistry.m[s]
+	if !ok {
+		l = newServerLimits()
+		serverLimitsRegistry.m[s] = l
+	}
+	return l
+}
+
+// SetMaxInMemoryBody sets the POST body size above which pollingConn switches
+// from buffering the whole request body to reading it as a stream.
+func (s *Server) SetMaxInMemoryBody(n int64) {
+	atomic.StoreInt64(&s.limits().maxInMemoryBody, n)
+}
+
+// SetMaxPacketSize caps the size of a single polling packet; a POST carrying
+// a larger packet is rejected with 413 before any of its packets are
+// delivered. The check runs after the whole POST body has been decoded, so an
+// oversized packet is still held in memory, up to SetMaxPayloadSize.
+func (s *Server) SetMaxPacketSize(n int64) {
+	atomic.StoreInt64(&s.limits().maxPacketSize, n)
+}
+
+// SetMaxPayloadSize caps the total size of a POST body, which may bundle
+// several packets back to back. It bounds the read from an unknown-length or
+// streamed body independently of SetMaxPacketSize, so a legitimate
+// multi-packet upload isn't rejected just because its combined size exceeds
+// any single packet's cap.
+func (s *Server) SetMaxPayloadSize(n int64) {
+	atomic.StoreInt64(&s.limits().maxPayloadSize, n)
+}
+
+// SetPayloadTimeout bounds how long a POST handler blocks trying to hand a
+// decoded packet to its session before giving up with 503.
+func (s *Server) SetPayloadTimeout(d time.Duration) {
+	atomic.StoreInt64(&s.limits().payloadTimeout, int64(d))
+}
+
+func (l *serverLimits) getMaxInMemoryBody() int64 { return atomic.LoadInt64(&l.maxInMemoryBody) }
+func (l *serverLimits) getMaxPacketSize() int64   { return atomic.LoadInt64(&l.maxPacketSize) }
+func (l *serverLimits) getMaxPayloadSize() int64  { return atomic.LoadInt64(&l.maxPayloadSize) }
+func (l *serverLimits) getPayloadTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&l.payloadTimeout))
+}
+
+type limitsCtxKey struct{}
+
+// withLimits attaches s's POST body limits to ctx. pollingConn.HandleFastHTTP
+// has no reference back to the *Server it belongs to, so Server.HandleFastHTTP
+// threads s's limits through the request instead of a package-wide default.
+func withLimits(ctx *fasthttp.RequestCtx, s *Server) {
+	ctx.SetUserValue(limitsCtxKey{}, s.limits())
+}
+
+// limitsFromCtx retrieves the limits withLimits attached, or a fresh set of
+// defaults if none were (e.g. HandleFastHTTP invoked directly in a test).
+func limitsFromCtx(ctx *fasthttp.RequestCtx) *serverLimits {
+	if l, ok := ctx.UserValue(limitsCtxKey{}).(*serverLimits); ok {
+		return l
+	}
+	return newServerLimits()
+}